@@ -0,0 +1,135 @@
+// Copyright 2017 Bulat Gaifullin.  All rights reserved.
+// Use of this source code is governed by a MIT license.
+
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// cacheRoot is the directory holding one shared, bare clone per remote
+// repository, from which per-dependency work trees are derived. It
+// defaults under the user's temp directory but can be pinned with
+// GOVER_CACHE, e.g. to share it across CI runs.
+func cacheRoot() string {
+	if dir := os.Getenv("GOVER_CACHE"); dir != "" {
+		return dir
+	}
+	return filepath.Join(os.TempDir(), "gover-cache")
+}
+
+// cacheDirFor returns the cache directory for repo, keyed by the hash
+// of its URL so unrelated remotes never collide.
+func cacheDirFor(v *vcsCmd, repo string) string {
+	sum := sha256.Sum256([]byte(repo))
+	return filepath.Join(cacheRoot(), v.cmd, fmt.Sprintf("%x", sum))
+}
+
+// cacheGitDirFor returns the shared bare clone backing repo's work tree
+// for v, or "" if v has no shared cache (see resolveOne) and a
+// dependency's dir therefore holds its own vcs meta directly.
+func cacheGitDirFor(v *vcsCmd, repo string) string {
+	if v.bareCloneCmd == "" || v.worktreeCmd == "" {
+		return ""
+	}
+	return cacheDirFor(v, repo)
+}
+
+// fetchGroup coalesces concurrent requests for the same remote so only
+// one of them clones or fetches; the rest wait for it to finish.
+var fetchGroup singleflight.Group
+
+// fetchCache makes sure a shared bare clone of repo exists and contains
+// rev, creating the clone on first use. Concurrent callers for the same
+// repo are coalesced so only one of them creates the clone; the rest
+// wait for it to finish. When depth is greater than zero, the initial
+// clone (if the cache does not exist yet) and any later fetch of a new
+// rev into an existing cache are both limited to that many commits,
+// falling back to a full clone/fetch if the shallow one fails.
+func fetchCache(v *vcsCmd, repo, rev string, depth int) (string, error) {
+	if v.bareCloneCmd == "" {
+		return "", fmt.Errorf("gover: %s does not support a shared cache", v.name)
+	}
+	cacheDir := cacheDirFor(v, repo)
+	_, err, _ := fetchGroup.Do(cacheDir, func() (interface{}, error) {
+		if bareCloneExists(cacheDir) {
+			return nil, nil
+		}
+		if err := os.MkdirAll(filepath.Dir(cacheDir), 0755); err != nil {
+			return nil, err
+		}
+		if depth > 0 && v.shallowBareCloneCmd != "" {
+			if err := v.run(".", v.shallowBareCloneCmd, "dir", cacheDir, "repo", repo, "depth", strconv.Itoa(depth)); err == nil {
+				return nil, nil
+			}
+			log.Printf("gover: shallow clone of %s failed, falling back to a full clone\n", repo)
+		}
+		return nil, v.run(".", v.bareCloneCmd, "dir", cacheDir, "repo", repo)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	// Serialize fetch+checkout per cache dir (see lockCacheDir below): a
+	// fetch racing a concurrent checkout in the same git-dir is just as
+	// unsafe as two concurrent checkouts.
+	unlock := lockCacheDir(cacheDir)
+	defer unlock()
+
+	if v.hasRevision(cacheDir, rev) {
+		// already present from an earlier clone/fetch; skip hitting the
+		// network again, as chunk0-2 asks for.
+		return cacheDir, nil
+	}
+	if depth > 0 && v.shallowFetchCmd != "" {
+		if err := v.run(cacheDir, v.shallowFetchCmd, "version", rev, "depth", strconv.Itoa(depth)); err == nil {
+			return cacheDir, nil
+		}
+		log.Printf("gover: shallow fetch of %s failed, falling back to a full fetch\n", rev)
+	}
+	return cacheDir, v.runVerboseOnly(cacheDir, v.fetchCmd)
+}
+
+// bareCloneExists reports whether dir already holds a bare clone. A
+// bare clone has no nested v.meta directory: its metadata, notably
+// HEAD, lives directly in dir, unlike a worktree-style checkout.
+func bareCloneExists(dir string) bool {
+	_, err := os.Stat(filepath.Join(dir, "HEAD"))
+	return err == nil
+}
+
+// checkoutLocks serializes derivations of work trees that share a cache
+// dir: two `git --git-dir=<cache>` checkouts running at once would race
+// on the shared git-dir's index and lock files, so only one dependency
+// may check out of a given cache dir at a time. Unrelated cache dirs
+// still proceed in parallel.
+var checkoutLocks sync.Map // map[string]*sync.Mutex
+
+func lockCacheDir(cacheDir string) func() {
+	mu, _ := checkoutLocks.LoadOrStore(cacheDir, &sync.Mutex{})
+	m := mu.(*sync.Mutex)
+	m.Lock()
+	return m.Unlock
+}
+
+// worktreeCheckout derives a work tree for rev at dst from the shared
+// bare clone at cacheDir, leaving the clone itself untouched.
+func (v *vcsCmd) worktreeCheckout(cacheDir, dst, rev string) error {
+	if v.worktreeCmd == "" {
+		return fmt.Errorf("gover: %s does not support a shared cache", v.name)
+	}
+	if err := os.MkdirAll(dst, 0755); err != nil {
+		return err
+	}
+	unlock := lockCacheDir(cacheDir)
+	defer unlock()
+	return v.run(".", v.worktreeCmd, "gitdir", cacheDir, "worktree", dst, "version", rev)
+}
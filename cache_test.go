@@ -0,0 +1,51 @@
+// Copyright 2017 Bulat Gaifullin.  All rights reserved.
+// Use of this source code is governed by a MIT license.
+
+package main
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLockCacheDirSerializesSameDir(t *testing.T) {
+	var running int32
+	var sawOverlap int32
+
+	work := func() {
+		unlock := lockCacheDir("/cache/same")
+		defer unlock()
+		if !atomic.CompareAndSwapInt32(&running, 0, 1) {
+			atomic.StoreInt32(&sawOverlap, 1)
+		}
+		time.Sleep(5 * time.Millisecond)
+		atomic.StoreInt32(&running, 0)
+	}
+
+	done := make(chan struct{})
+	go func() { work(); close(done) }()
+	work()
+	<-done
+
+	if atomic.LoadInt32(&sawOverlap) != 0 {
+		t.Fatal("lockCacheDir let two checkouts of the same cache dir run concurrently")
+	}
+}
+
+func TestLockCacheDirAllowsDifferentDirs(t *testing.T) {
+	done := make(chan struct{})
+	unlockA := lockCacheDir("/cache/a")
+	go func() {
+		unlockB := lockCacheDir("/cache/b")
+		unlockB()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("lockCacheDir blocked an unrelated cache dir")
+	}
+	unlockA()
+}
@@ -0,0 +1,118 @@
+// Copyright 2017 Bulat Gaifullin.  All rights reserved.
+// Use of this source code is governed by a MIT license.
+
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// skipFunc decides whether path (relative to the tree root) should be
+// excluded from a checksum.
+type skipFunc func(rel string, info os.FileInfo) bool
+
+// hashMode selects how much of a dependency's checkout contributes to
+// its checksum.
+type hashMode int
+
+const (
+	// hashModeTree hashes the whole checked-out directory tree.
+	hashModeTree hashMode = iota
+	// hashModePackage hashes only the files directly inside the root,
+	// ignoring nested subdirectories.
+	hashModePackage
+)
+
+// skipVcsMeta is a skipFunc that excludes the meta directory of every
+// known vcs, so checksums are stable across re-clones of the same
+// revision.
+func skipVcsMeta(rel string, info os.FileInfo) bool {
+	if !info.IsDir() {
+		return false
+	}
+	name := info.Name()
+	for _, vcs := range vcsList {
+		if name == vcs.meta {
+			return true
+		}
+	}
+	return false
+}
+
+// hashTree writes the contents of dir into h, hashing each file's path
+// relative to dir followed by its contents. Each record is framed as
+// "<path-length>:<path><content-length>:<content>" so that neither a
+// path/content boundary nor an entry/entry boundary is ambiguous (a file
+// named "ab" with empty contents must not hash the same as a file named
+// "a" with contents "b"). filepath.Walk visits entries in lexical order,
+// so the result does not depend on directory ordering on disk. skip,
+// when non-nil, excludes paths (and, for directories, everything
+// beneath them) from the hash.
+func hashTree(h hash.Hash, dir string, mode hashMode, skip skipFunc) error {
+	return filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			return nil
+		}
+		if skip != nil && skip(rel, info) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		nested := strings.Contains(rel, string(os.PathSeparator))
+		if info.IsDir() {
+			if mode == hashModePackage && nested {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if mode == hashModePackage && nested {
+			return nil
+		}
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		fmt.Fprintf(h, "%d:%s%d:", len(rel), rel, info.Size())
+		_, err = io.Copy(h, f)
+		return err
+	})
+}
+
+// checksum returns the hex-encoded SHA-256 checksum of the directory
+// tree rooted at dir.
+func checksum(dir string, mode hashMode, skip skipFunc) (string, error) {
+	h := sha256.New()
+	if err := hashTree(h, dir, mode, skip); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+// verifyChecksum recomputes the checksum of dir and compares it against
+// want, the value recorded in the manifest for pkg. It returns an error
+// naming pkg when the checkout is out of date.
+func verifyChecksum(pkg, dir, want string, mode hashMode, skip skipFunc) error {
+	got, err := checksum(dir, mode, skip)
+	if err != nil {
+		return err
+	}
+	if got != want {
+		return fmt.Errorf("gover: %s is out of date: checksum mismatch (want %s, got %s)", pkg, want, got)
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+// Copyright 2017 Bulat Gaifullin.  All rights reserved.
+// Use of this source code is governed by a MIT license.
+
+package main
+
+import (
+	"crypto/sha256"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeTree(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "gover-checksum")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		p := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestChecksumDistinguishesPathContentBoundary(t *testing.T) {
+	dirA := writeTree(t, map[string]string{"ab": ""})
+	dirB := writeTree(t, map[string]string{"a": "b"})
+	defer os.RemoveAll(dirA)
+	defer os.RemoveAll(dirB)
+
+	sumA, err := checksum(dirA, hashModeTree, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sumB, err := checksum(dirB, hashModeTree, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sumA == sumB {
+		t.Fatalf("checksum for %q and %q collided: %s", dirA, dirB, sumA)
+	}
+}
+
+func TestChecksumStableAndDetectsMismatch(t *testing.T) {
+	dir := writeTree(t, map[string]string{"a.go": "package a", "sub/b.go": "package b"})
+	defer os.RemoveAll(dir)
+
+	want, err := checksum(dir, hashModeTree, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := verifyChecksum("pkg", dir, want, hashModeTree, nil); err != nil {
+		t.Fatalf("verifyChecksum on unmodified tree: %v", err)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "a.go"), []byte("package a // tampered"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := verifyChecksum("pkg", dir, want, hashModeTree, nil); err == nil {
+		t.Fatal("verifyChecksum did not detect a tampered file")
+	}
+}
+
+func TestHashModePackageIgnoresNestedDirs(t *testing.T) {
+	dir := writeTree(t, map[string]string{"a.go": "package a", "sub/b.go": "package b"})
+	defer os.RemoveAll(dir)
+
+	sumPackage, err := checksum(dir, hashModePackage, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "sub", "b.go"), []byte("package b // tampered"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := verifyChecksum("pkg", dir, sumPackage, hashModePackage, nil); err != nil {
+		t.Fatalf("hashModePackage should ignore changes under a nested subdirectory: %v", err)
+	}
+	if err := verifyChecksum("pkg", dir, sumPackage, hashModeTree, nil); err == nil {
+		t.Fatal("hashModeTree should have detected the change under sub/")
+	}
+}
+
+func TestSkipVcsMeta(t *testing.T) {
+	dir := writeTree(t, map[string]string{".git/HEAD": "ref: refs/heads/master", "a.go": "package a"})
+	defer os.RemoveAll(dir)
+
+	h := sha256.New()
+	if err := hashTree(h, dir, hashModeTree, skipVcsMeta); err != nil {
+		t.Fatal(err)
+	}
+
+	clean := writeTree(t, map[string]string{"a.go": "package a"})
+	defer os.RemoveAll(clean)
+	h2 := sha256.New()
+	if err := hashTree(h2, clean, hashModeTree, skipVcsMeta); err != nil {
+		t.Fatal(err)
+	}
+	if string(h.Sum(nil)) != string(h2.Sum(nil)) {
+		t.Fatal("skipVcsMeta did not exclude .git from the checksum")
+	}
+}
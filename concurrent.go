@@ -0,0 +1,111 @@
+// Copyright 2017 Bulat Gaifullin.  All rights reserved.
+// Use of this source code is governed by a MIT license.
+
+package main
+
+import (
+	"runtime"
+	"sync"
+)
+
+// dependency is a single entry from the manifest that needs to be
+// resolved against the shared cache.
+type dependency struct {
+	Name    string `json:"name"`
+	Repo    string `json:"repo"`
+	Version string `json:"version"`
+	Dir     string `json:"dir"`
+
+	// Package, when true, limits the checksum recorded for this
+	// dependency to the files directly inside Dir (hashModePackage)
+	// instead of the whole checked-out tree (hashModeTree). Useful when
+	// only a single package of a larger repository is vendored and
+	// unrelated subdirectories should not affect its checksum.
+	Package bool `json:"package,omitempty"`
+}
+
+// hashModeFor returns the hashMode a dependency's checksum should be
+// computed with, per its Package flag.
+func hashModeFor(dep dependency) hashMode {
+	if dep.Package {
+		return hashModePackage
+	}
+	return hashModeTree
+}
+
+// concurrency returns how many dependencies gover may resolve at once,
+// preferring an explicit -j value and otherwise using GOMAXPROCS.
+func concurrency(j int) int {
+	if j > 0 {
+		return j
+	}
+	return runtime.GOMAXPROCS(0)
+}
+
+// resolveAll resolves every dependency in deps against the shared
+// work-tree cache, running up to concurrency(j) of them at once. depth,
+// when greater than zero, limits the history of both the initial clone
+// of a remote and any later fetch of a new rev into it (see
+// fetchCache). fetchCache creates the shared clone of a remote only
+// once regardless of how many dependencies reference it, and runs later
+// fetches into it one at a time, skipping revs already present. The
+// returned slice has one entry per dependency, in the same order as
+// deps.
+func resolveAll(deps []dependency, j, depth int) []error {
+	sem := make(chan struct{}, concurrency(j))
+	errs := make([]error, len(deps))
+	var wg sync.WaitGroup
+	for i, dep := range deps {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, dep dependency) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			errs[i] = resolveOne(dep, depth)
+		}(i, dep)
+	}
+	wg.Wait()
+	return errs
+}
+
+// resolveOne resolves dep against the shared work-tree cache. Only git
+// supports that cache (bareCloneCmd/worktreeCmd are unset for
+// Mercurial/Bazaar/Subversion), so any other vcs falls back to
+// resolveDirect, the plain create/checkout path those vcs do support.
+func resolveOne(dep dependency, depth int) error {
+	v := getVcsByUrl(dep.Repo, dep.Dir)
+	if v.bareCloneCmd == "" || v.worktreeCmd == "" {
+		return resolveDirect(v, dep, depth)
+	}
+	rev := refFor(v, dep.Version)
+	cacheDir, err := fetchCache(v, dep.Repo, rev, depth)
+	if err != nil {
+		return err
+	}
+	return v.worktreeCheckout(cacheDir, dep.Dir, rev)
+}
+
+// resolveDirect resolves dep by cloning or checking it out directly into
+// dep.Dir, without a shared cache. It is the fallback for vcs that don't
+// support a shared bare clone.
+func resolveDirect(v *vcsCmd, dep dependency, depth int) error {
+	if v.exists(dep.Dir) {
+		return v.checkout(dep.Dir, dep.Version)
+	}
+	if depth > 0 {
+		return v.createShallow(dep.Dir, dep.Repo, dep.Version, depth)
+	}
+	return v.create(dep.Dir, dep.Repo, dep.Version)
+}
+
+// refFor returns the ref that identifies version for a checkout: the
+// pinned commit for a "sha:" version, the tag otherwise. It mirrors the
+// tag/commit split that create and checkout already apply via
+// parseVersion, so the shared-cache path stays in sync with them.
+func refFor(v *vcsCmd, version string) string {
+	tag, commit := v.parseVersion(version)
+	if commit != "" {
+		return commit
+	}
+	return tag
+}
@@ -0,0 +1,32 @@
+// Copyright 2017 Bulat Gaifullin.  All rights reserved.
+// Use of this source code is governed by a MIT license.
+
+package main
+
+import "testing"
+
+func TestHashModeFor(t *testing.T) {
+	if got := hashModeFor(dependency{}); got != hashModeTree {
+		t.Errorf("hashModeFor(%+v) = %v, want hashModeTree", dependency{}, got)
+	}
+	pkg := dependency{Package: true}
+	if got := hashModeFor(pkg); got != hashModePackage {
+		t.Errorf("hashModeFor(%+v) = %v, want hashModePackage", pkg, got)
+	}
+}
+
+func TestRefFor(t *testing.T) {
+	cases := []struct {
+		version string
+		want    string
+	}{
+		{"sha:deadbeef", "deadbeef"},
+		{"v1.0.0", "v1.0.0"},
+		{"master", "master"},
+	}
+	for _, c := range cases {
+		if got := refFor(vcsGit, c.version); got != c.want {
+			t.Errorf("refFor(%q) = %q, want %q", c.version, got, c.want)
+		}
+	}
+}
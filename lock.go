@@ -0,0 +1,99 @@
+// Copyright 2017 Bulat Gaifullin.  All rights reserved.
+// Use of this source code is governed by a MIT license.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+)
+
+// lockFileName is the name of the file gover writes provenance into,
+// alongside the manifest.
+const lockFileName = "gover.lock"
+
+// lockEntry is the on-disk representation of a vcsStatus for a single
+// dependency.
+type lockEntry struct {
+	Revision    string
+	CommitTime  string
+	Uncommitted bool
+	Tag         string
+	Checksum    string
+}
+
+// readLockFile reads the lockfile at path. A missing file is not an
+// error: it is treated as an empty lockfile, as happens on the very
+// first install.
+func readLockFile(path string) (map[string]lockEntry, error) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return map[string]lockEntry{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	locks := map[string]lockEntry{}
+	if err := json.Unmarshal(data, &locks); err != nil {
+		return nil, err
+	}
+	return locks, nil
+}
+
+// writeLockFile stamps locks into the lockfile at path.
+func writeLockFile(path string, locks map[string]lockEntry) error {
+	data, err := json.MarshalIndent(locks, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+// lockEntryFromStatus converts a vcsStatus, as returned by vcsCmd.Status,
+// into the lockEntry recorded for its package.
+func lockEntryFromStatus(s *vcsStatus) lockEntry {
+	return lockEntry{
+		Revision:    s.Revision,
+		CommitTime:  s.CommitTime,
+		Uncommitted: s.Uncommitted,
+		Tag:         s.Tag,
+	}
+}
+
+// checkLock verifies that the dependency pkg, checked out at dir, still
+// matches what was recorded in locks. It refuses uncommitted changes or
+// a revision mismatch against the lockfile unless force is set, in which
+// case it only warns. gitDir is passed through to v.Status (see
+// cacheGitDirFor). A vcs that does not support Status at all is not
+// something checkLock can verify, so it is skipped with a warning
+// rather than failing the whole install.
+func checkLock(v *vcsCmd, pkg, dir, gitDir string, locks map[string]lockEntry, force bool) error {
+	want, ok := locks[pkg]
+	if !ok {
+		return nil
+	}
+	status, err := v.Status(dir, gitDir)
+	if err != nil {
+		if v.revisionCmd == "" {
+			log.Printf("gover: warning: %s does not support status; skipping lock check for %s\n", v.name, pkg)
+			return nil
+		}
+		return err
+	}
+	if status.Uncommitted {
+		if !force {
+			return fmt.Errorf("gover: %s has uncommitted changes; rerun with -force to install anyway", pkg)
+		}
+		log.Printf("gover: warning: %s has uncommitted changes\n", pkg)
+	}
+	if status.Revision != want.Revision {
+		if !force {
+			return fmt.Errorf("gover: %s is at revision %s, lockfile wants %s; rerun with -force to install anyway", pkg, status.Revision, want.Revision)
+		}
+		log.Printf("gover: warning: %s is at revision %s, lockfile wants %s\n", pkg, status.Revision, want.Revision)
+	}
+	return nil
+}
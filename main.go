@@ -0,0 +1,123 @@
+// Copyright 2017 Bulat Gaifullin.  All rights reserved.
+// Use of this source code is governed by a MIT license.
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+)
+
+// manifestFileName is the manifest gover reads the dependency list from.
+const manifestFileName = "gover.json"
+
+// manifest is the on-disk format of manifestFileName.
+type manifest struct {
+	Dependencies []dependency `json:"dependencies"`
+}
+
+// readManifest reads and parses the dependency list from path.
+func readManifest(path string) ([]dependency, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var m manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	return m.Dependencies, nil
+}
+
+func main() {
+	force := flag.Bool("force", false, "install a dependency even if it has uncommitted changes or has drifted from gover.lock")
+	j := flag.Int("j", 0, "number of dependencies to resolve concurrently (default GOMAXPROCS)")
+	shallow := flag.Bool("shallow", false, "clone with limited history (depth 1 unless -depth is also set)")
+	depth := flag.Int("depth", 0, "limit clone/fetch history to N commits (implies -shallow)")
+	flag.Parse()
+
+	if err := install(*force, *j, shallowDepth(*shallow, *depth)); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// dirExists reports whether dir is already present on disk. Unlike
+// vcsCmd.exists, it does not look for a vcs-specific meta directory: a
+// dependency resolved through the chunk0-5 shared-cache path is a plain
+// worktree checkout with no nested .git, so meta-directory presence is
+// not a reliable signal that a dependency has already been installed.
+func dirExists(dir string) bool {
+	_, err := os.Stat(dir)
+	return err == nil
+}
+
+// install resolves every dependency listed in the manifest, up to j of
+// them at once (see resolveAll) and with history limited to depth
+// commits when depth is greater than zero (see shallowDepth), and
+// stamps its provenance into gover.lock. If a dependency already
+// checked out on disk has uncommitted changes or a revision that
+// differs from the lockfile, install returns an error before resolving
+// any dependency, unless force is set. A dependency whose vcs does not support Status (see
+// vcsCmd.revisionCmd) is left out of gover.lock instead of failing the
+// install outright.
+func install(force bool, j, depth int) error {
+	deps, err := readManifest(manifestFileName)
+	if err != nil {
+		return err
+	}
+	locks, err := readLockFile(lockFileName)
+	if err != nil {
+		return err
+	}
+
+	for _, dep := range deps {
+		if !dirExists(dep.Dir) {
+			continue
+		}
+		v := getVcsByUrl(dep.Repo, dep.Dir)
+		if err := checkLock(v, dep.Name, dep.Dir, cacheGitDirFor(v, dep.Repo), locks, force); err != nil {
+			return err
+		}
+		if want, ok := locks[dep.Name]; ok && want.Checksum != "" {
+			if err := verifyChecksum(dep.Name, dep.Dir, want.Checksum, hashModeFor(dep), skipVcsMeta); err != nil {
+				if !force {
+					return err
+				}
+				log.Printf("gover: warning: %v\n", err)
+			}
+		}
+	}
+
+	errs := resolveAll(deps, j, depth)
+	for i, err := range errs {
+		if err != nil {
+			return fmt.Errorf("gover: %s: %v", deps[i].Name, err)
+		}
+	}
+
+	newLocks := make(map[string]lockEntry, len(deps))
+	for _, dep := range deps {
+		v := getVcsByUrl(dep.Repo, dep.Dir)
+		status, err := v.Status(dep.Dir, cacheGitDirFor(v, dep.Repo))
+		if err != nil {
+			if v.revisionCmd == "" {
+				log.Printf("gover: warning: %s does not support status; omitting it from gover.lock\n", dep.Name)
+				continue
+			}
+			return err
+		}
+		sum, err := checksum(dep.Dir, hashModeFor(dep), skipVcsMeta)
+		if err != nil {
+			return err
+		}
+		entry := lockEntryFromStatus(status)
+		entry.Checksum = sum
+		newLocks[dep.Name] = entry
+	}
+
+	return writeLockFile(lockFileName, newLocks)
+}
@@ -0,0 +1,75 @@
+// Copyright 2017 Bulat Gaifullin.  All rights reserved.
+// Use of this source code is governed by a MIT license.
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+)
+
+// shallowDepth resolves the -shallow/-depth flags into the depth passed
+// to createShallow: 0 means a full clone, matching create/checkout.
+func shallowDepth(shallow bool, depth int) int {
+	if depth > 0 {
+		return depth
+	}
+	if shallow {
+		return 1
+	}
+	return 0
+}
+
+// createShallow is like create but clones with history limited to
+// depth, which trims bandwidth and disk for dependencies with large
+// histories. It falls back to a full create if the vcs doesn't support
+// shallow clones, or if the shallow clone itself fails.
+func (v *vcsCmd) createShallow(dir, repo, version string, depth int) error {
+	if depth <= 0 || v.shallowCreateCmd == "" {
+		return v.create(dir, repo, version)
+	}
+	tag, commit := v.parseVersion(version)
+	if err := v.run(".", v.shallowCreateCmd, "dir", dir, "repo", repo, "branch", tag, "depth", strconv.Itoa(depth)); err != nil {
+		log.Printf("gover: shallow clone of %s failed, falling back to a full clone\n", repo)
+		return v.create(dir, repo, version)
+	}
+	if commit == "" {
+		return nil
+	}
+	if err := v.checkoutShallow(dir, commit, depth); err != nil {
+		log.Printf("gover: shallow fetch of %s failed, backfilling full history\n", commit)
+		return v.unshallow(dir, commit)
+	}
+	return nil
+}
+
+// unshallow backfills the full history of a shallow clone at dir and
+// then checks out rev. It is the fallback for a shallow fetch that a
+// server rejected, e.g. because it did not advertise
+// uploadpack.allowReachableSHA1InWant for an arbitrary commit: the
+// commit was never fetched, so retrying the same checkout in the
+// shallow clone can never succeed.
+func (v *vcsCmd) unshallow(dir, rev string) error {
+	if v.unshallowFetchCmd == "" {
+		return fmt.Errorf("gover: %s does not support unshallowing", v.name)
+	}
+	if err := v.run(dir, v.unshallowFetchCmd); err != nil {
+		return err
+	}
+	return v.run(dir, v.checkoutCmd, "version", rev)
+}
+
+// checkoutShallow fetches just rev, with history limited to depth, and
+// checks it out. Some servers reject fetching an arbitrary commit
+// unless they advertise uploadpack.allowReachableSHA1InWant; callers
+// should treat an error here as a signal to fall back to a full fetch.
+func (v *vcsCmd) checkoutShallow(dir, rev string, depth int) error {
+	if v.shallowFetchCmd == "" {
+		return fmt.Errorf("gover: %s does not support shallow fetch", v.name)
+	}
+	if err := v.run(dir, v.shallowFetchCmd, "version", rev, "depth", strconv.Itoa(depth)); err != nil {
+		return err
+	}
+	return v.run(dir, v.checkoutCmd, "version", "FETCH_HEAD")
+}
@@ -0,0 +1,31 @@
+// Copyright 2017 Bulat Gaifullin.  All rights reserved.
+// Use of this source code is governed by a MIT license.
+
+package main
+
+import "testing"
+
+func TestShallowDepth(t *testing.T) {
+	cases := []struct {
+		shallow bool
+		depth   int
+		want    int
+	}{
+		{false, 0, 0},
+		{true, 0, 1},
+		{false, 5, 5},
+		{true, 5, 5},
+	}
+	for _, c := range cases {
+		if got := shallowDepth(c.shallow, c.depth); got != c.want {
+			t.Errorf("shallowDepth(%v, %d) = %d, want %d", c.shallow, c.depth, got, c.want)
+		}
+	}
+}
+
+func TestUnshallowRequiresSupport(t *testing.T) {
+	v := &vcsCmd{name: "Stub"}
+	if err := v.unshallow("/tmp/does-not-matter", "deadbeef"); err == nil {
+		t.Fatal("unshallow should fail for a vcs with no unshallowFetchCmd instead of retrying the failed checkout")
+	}
+}
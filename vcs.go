@@ -5,6 +5,7 @@ package main
 
 import (
 	"bytes"
+	"fmt"
 	"log"
 	"os"
 	"os/exec"
@@ -22,11 +23,38 @@ type vcsCmd struct {
 	createCmd   string // command to download a fresh copy of a repository
 	downloadCmd string // command to download updates into an existing repository
 	checkoutCmd string
+	existsCmd   string // command to check whether a revision is already present locally
+	fetchCmd    string // command to fetch new objects from origin without checking anything out
+
+	revisionCmd   string // command to print the currently checked-out revision
+	commitTimeCmd string // command to print the commit time of the currently checked-out revision
+	statusCmd     string // command to print uncommitted changes, empty output means clean
+	describeCmd   string // command to print the nearest tag, if any
+
+	bareCloneCmd        string // command to create a shared, work-tree-less clone of a repository
+	shallowBareCloneCmd string // command to create a shared clone with limited history
+	worktreeCmd         string // command to derive a work tree for a revision from a shared clone
+
+	shallowCreateCmd  string // command to clone with limited history on a single branch
+	shallowFetchCmd   string // command to fetch a single revision with limited history
+	unshallowFetchCmd string // command to backfill full history into a shallow clone
+}
+
+// vcsStatus describes the state of a dependency's local checkout, as
+// recorded in a gover.lock file.
+type vcsStatus struct {
+	Revision    string
+	CommitTime  string
+	Uncommitted bool
+	Tag         string
 }
 
 // vcsList lists the known version control systems
 var vcsList = []*vcsCmd{
 	vcsGit,
+	vcsHg,
+	vcsBzr,
+	vcsSvn,
 }
 
 // vcsByCmd returns the version control system for the given
@@ -48,11 +76,116 @@ var vcsGit = &vcsCmd{
 
 	createCmd:   "clone {repo} {dir} -b {branch}",
 	downloadCmd: "checkout -f tags/{tag}",
-	checkoutCmd: "checkout {version}",
+	checkoutCmd: "checkout --force {version}",
+	existsCmd:   "cat-file -e {version}",
+	fetchCmd:    "fetch --quiet origin",
+
+	revisionCmd:   "rev-parse HEAD",
+	commitTimeCmd: "log -1 --format=%cI",
+	statusCmd:     "status --porcelain",
+	describeCmd:   "describe --tags",
+
+	bareCloneCmd:        "clone --bare {repo} {dir}",
+	shallowBareCloneCmd: "clone --bare --depth={depth} {repo} {dir}",
+	worktreeCmd:         "--git-dir={gitdir} --work-tree={worktree} checkout -f {version}",
+
+	shallowCreateCmd:  "clone --depth={depth} --single-branch --branch {branch} {repo} {dir}",
+	shallowFetchCmd:   "fetch --depth={depth} origin {version}",
+	unshallowFetchCmd: "fetch --unshallow origin",
+}
+
+// vcsHg describes how to use Mercurial.
+var vcsHg = &vcsCmd{
+	name: "Mercurial",
+	cmd:  "hg",
+	meta: ".hg",
+
+	createCmd:   "clone {repo} {dir} -b {branch}",
+	downloadCmd: "update {tag}",
+	checkoutCmd: "update -r {version}",
+}
+
+// vcsBzr describes how to use Bazaar.
+var vcsBzr = &vcsCmd{
+	name: "Bazaar",
+	cmd:  "bzr",
+	meta: ".bzr",
+
+	createCmd:   "branch {repo} {dir}",
+	downloadCmd: "update -r {tag}",
+	checkoutCmd: "update -r {version}",
 }
 
-func getVcsByUrl(url string) *vcsCmd {
-	// there is no other vcs except git
+// vcsSvn describes how to use Subversion.
+var vcsSvn = &vcsCmd{
+	name: "Subversion",
+	cmd:  "svn",
+	meta: ".svn",
+
+	createCmd:   "checkout {repo} {dir}",
+	downloadCmd: "update -r {tag}",
+	checkoutCmd: "update -r {version}",
+}
+
+// vcsHostSuffixes maps well-known URL host/path suffixes to the vcs
+// that serves them.
+var vcsHostSuffixes = []struct {
+	suffix string
+	vcs    *vcsCmd
+}{
+	{".git", vcsGit},
+	{".hg", vcsHg},
+	{".bzr", vcsBzr},
+	{".svn", vcsSvn},
+	{"bitbucket.org", vcsHg},
+}
+
+// vcsUrlPrefixes maps well-known URL prefixes to the vcs that serves them.
+var vcsUrlPrefixes = []struct {
+	prefix string
+	vcs    *vcsCmd
+}{
+	{"hg.", vcsHg},
+	{"svn.", vcsSvn},
+	{"bzr.", vcsBzr},
+}
+
+// getVcsByUrl guesses the version control system for the given
+// repository URL by inspecting known host prefixes and suffixes.
+// Explicit host prefixes (e.g. "svn.") are checked before suffixes, so
+// they are not shadowed by a coincidental suffix match elsewhere in the
+// URL. If the URL gives no hint and dst is an existing checkout, it
+// falls back to probing dst for a known meta directory.
+func getVcsByUrl(url string, dst string) *vcsCmd {
+	host := url
+	if i := strings.Index(host, "://"); i >= 0 {
+		host = host[i+len("://"):]
+	}
+	if i := strings.IndexByte(host, '/'); i >= 0 {
+		host = host[:i]
+	}
+
+	for _, m := range vcsUrlPrefixes {
+		if strings.HasPrefix(host, m.prefix) {
+			return m.vcs
+		}
+	}
+
+	for _, m := range vcsHostSuffixes {
+		if strings.HasSuffix(url, m.suffix) || strings.HasSuffix(host, m.suffix) {
+			return m.vcs
+		}
+	}
+
+	if dst != "" {
+		for _, vcs := range vcsList {
+			if vcs.exists(dst) {
+				return vcs
+			}
+		}
+	}
+
+	// default to git when nothing else matches
 	return vcsGit
 }
 
@@ -69,6 +202,70 @@ func (v *vcsCmd) exists(dst string) bool {
 	return err == nil || !os.IsNotExist(err)
 }
 
+// hasRevision reports whether rev is already present in the local
+// repository at dir, so a fetch can be skipped on a cache hit.
+func (v *vcsCmd) hasRevision(dir, rev string) bool {
+	if v.existsCmd == "" {
+		return false
+	}
+	_, err := v.run1(dir, v.existsCmd, []string{"version", rev}, false)
+	return err == nil
+}
+
+// fetchRevision makes sure rev is available in the local repository
+// at dir, fetching from origin only if it is not already present.
+func (v *vcsCmd) fetchRevision(dir, rev string) error {
+	if v.fetchCmd == "" || v.hasRevision(dir, rev) {
+		return nil
+	}
+	return v.run(dir, v.fetchCmd)
+}
+
+// Status reports the revision, commit time, nearest tag and cleanliness
+// of the repository checked out at dir, so it can be stamped into a
+// gover.lock file for reproducible builds. gitDir, when non-empty, is
+// the shared bare clone backing dir (see cacheGitDirFor): dir itself is
+// then a cache-derived work tree with no vcs meta of its own, so every
+// command runs against gitDir with dir passed as an explicit work tree
+// instead of relying on meta discovered from dir.
+func (v *vcsCmd) Status(dir, gitDir string) (*vcsStatus, error) {
+	if v.revisionCmd == "" {
+		return nil, fmt.Errorf("gover: %s does not support status", v.name)
+	}
+	rev, err := v.runStatusCmd(dir, gitDir, v.revisionCmd)
+	if err != nil {
+		return nil, err
+	}
+	ct, err := v.runStatusCmd(dir, gitDir, v.commitTimeCmd)
+	if err != nil {
+		return nil, err
+	}
+	st, err := v.runStatusCmd(dir, gitDir, v.statusCmd)
+	if err != nil {
+		return nil, err
+	}
+	// a commit need not be tagged, so a failure here is not fatal.
+	tag, _ := v.runStatusCmd(dir, gitDir, v.describeCmd)
+
+	return &vcsStatus{
+		Revision:    strings.TrimSpace(string(rev)),
+		CommitTime:  strings.TrimSpace(string(ct)),
+		Uncommitted: len(strings.TrimSpace(string(st))) > 0,
+		Tag:         strings.TrimSpace(string(tag)),
+	}, nil
+}
+
+// runStatusCmd runs cmd, one of the status-reporting command templates
+// (revisionCmd, commitTimeCmd, statusCmd, describeCmd), against dir. If
+// gitDir is set, cmd runs with an explicit --git-dir/--work-tree instead
+// of cmd.Dir, since dir has no vcs meta of its own in that case.
+func (v *vcsCmd) runStatusCmd(dir, gitDir, cmd string) ([]byte, error) {
+	if gitDir == "" {
+		return v.runOutput(dir, cmd)
+	}
+	return v.runOutput(".", "--git-dir={gitdir} --work-tree={worktree} "+cmd, "gitdir", gitDir, "worktree", dir)
+}
+
 // create creates a new copy of repo in dir.
 // The parent of dir must exist; dir must not.
 func (v *vcsCmd) create(dir, repo string, version string) error {
@@ -77,6 +274,9 @@ func (v *vcsCmd) create(dir, repo string, version string) error {
 		return err
 	}
 	if commit != "" {
+		if err := v.fetchRevision(dir, commit); err != nil {
+			return err
+		}
 		return v.run(dir, v.checkoutCmd, "version", commit)
 	}
 	return nil
@@ -86,6 +286,9 @@ func (v *vcsCmd) create(dir, repo string, version string) error {
 func (v *vcsCmd) checkout(dir string, version string) error {
 	tag, commit := v.parseVersion(version)
 	if commit != "" {
+		if err := v.fetchRevision(dir, commit); err != nil {
+			return err
+		}
 		return v.run(dir, v.checkoutCmd, "version", commit)
 	} else {
 		return v.run(dir, v.downloadCmd, "tag", tag)
@@ -0,0 +1,83 @@
+// Copyright 2017 Bulat Gaifullin.  All rights reserved.
+// Use of this source code is governed by a MIT license.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGetVcsByUrlHostSuffixAndPrefix(t *testing.T) {
+	cases := []struct {
+		url  string
+		want *vcsCmd
+	}{
+		{"https://github.com/foo/bar.git", vcsGit},
+		{"https://example.com/foo.hg", vcsHg},
+		{"https://bitbucket.org/foo/bar", vcsHg},
+		{"https://hg.example.com/foo", vcsHg},
+		{"https://svn.example.com/foo", vcsSvn},
+		{"https://example.com/foo.bzr", vcsBzr},
+		// "github.io" contains ".git" as a substring but does not end
+		// with it, so it must not be mistaken for a Git suffix match.
+		{"https://foo.github.io/bar", vcsGit},
+		// an explicit "svn." prefix must win even though the URL also
+		// ends with the ".git" suffix.
+		{"https://svn.github.io/some/repo.git", vcsSvn},
+	}
+	for _, c := range cases {
+		if got := getVcsByUrl(c.url, ""); got != c.want {
+			t.Errorf("getVcsByUrl(%q) = %s, want %s", c.url, got.name, c.want.name)
+		}
+	}
+}
+
+func TestGetVcsByUrlProbesDst(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gover-vcs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := os.Mkdir(filepath.Join(dir, ".hg"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	// a URL with no recognizable suffix or prefix falls back to probing
+	// an already-checked-out dst for a known meta directory.
+	if got := getVcsByUrl("https://example.com/unrecognizable", dir); got != vcsHg {
+		t.Errorf("getVcsByUrl did not probe dst: got %s, want Mercurial", got.name)
+	}
+}
+
+func TestGetVcsByUrlDefaultsToGit(t *testing.T) {
+	if got := getVcsByUrl("https://example.com/unrecognizable", ""); got != vcsGit {
+		t.Errorf("getVcsByUrl default = %s, want Git", got.name)
+	}
+}
+
+func TestHasRevisionWithoutExistsCmd(t *testing.T) {
+	// vcsHg has no existsCmd: hasRevision must report "not present"
+	// rather than panic or shell out to a command it doesn't have.
+	if vcsHg.hasRevision("/does/not/matter", "deadbeef") {
+		t.Fatal("hasRevision should be false when existsCmd is unset")
+	}
+}
+
+func TestFetchRevisionWithoutFetchCmd(t *testing.T) {
+	// vcsHg has no fetchCmd: fetchRevision must be a no-op rather than
+	// attempting to run an empty command line.
+	if err := vcsHg.fetchRevision("/does/not/matter", "deadbeef"); err != nil {
+		t.Fatalf("fetchRevision with no fetchCmd should be a no-op, got %v", err)
+	}
+}
+
+func TestStatusRequiresRevisionCmd(t *testing.T) {
+	// vcsHg does not set revisionCmd, so Status must fail clearly
+	// instead of running a command with an empty command line.
+	if _, err := vcsHg.Status("/does/not/matter", ""); err == nil {
+		t.Fatal("Status should fail when revisionCmd is unset")
+	}
+}